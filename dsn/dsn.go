@@ -21,14 +21,13 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
-	"github.com/shirou/gopsutil/net"
-	"github.com/shirou/gopsutil/process"
 )
 
 type DSN struct {
@@ -43,6 +42,19 @@ type DSN struct {
 	//
 	DefaultDb string
 	Params    []string
+	//
+	// TLSConfig, timeouts, charset/collation, and MaxAllowedPacket mirror
+	// the corresponding go-sql-driver/mysql DSN params (tls, readTimeout,
+	// writeTimeout, timeout, charset, collation, maxAllowedPacket). They're
+	// set by Parse and rendered back out by String; a zero value means the
+	// param is omitted, leaving the driver's own default in effect.
+	TLSConfig        string
+	ReadTimeout      string
+	WriteTimeout     string
+	Timeout          string
+	Charset          string
+	Collation        string
+	MaxAllowedPacket string
 }
 
 const (
@@ -58,7 +70,35 @@ var (
 	ErrNoSocket = errors.New("cannot auto-detect MySQL socket")
 )
 
+// SocketSelector picks one socket out of several candidates found on a
+// multi-instance host. The default, used by AutoDetect and when
+// AutoDetectWith is called with a nil selector, picks the first candidate
+// and matches the historical behavior of GetSocketFromProcessLists.
+type SocketSelector func([]SocketCandidate) (SocketCandidate, error)
+
+func firstSocket(candidates []SocketCandidate) (SocketCandidate, error) {
+	if len(candidates) > 1 {
+		sockets := make([]string, len(candidates))
+		for i, c := range candidates {
+			sockets[i] = c.Socket
+		}
+		log.Println("Multiple sockets detected, choosing first one:", strings.Join(sockets, ", "))
+	}
+	return candidates[0], nil
+}
+
+// AutoDetect fills in zero-value fields of dsn from the MySQL client
+// defaults and, for the socket, from the local host's running mysqld
+// instances. On a host running more than one mysqld, it picks the first
+// socket found; use AutoDetectWith to control that choice.
 func (dsn DSN) AutoDetect() (DSN, error) {
+	return dsn.AutoDetectWith(nil)
+}
+
+// AutoDetectWith is like AutoDetect, but selector is consulted whenever more
+// than one socket candidate is found on the host. Pass nil to keep
+// AutoDetect's "first one wins" behavior.
+func (dsn DSN) AutoDetectWith(selector SocketSelector) (DSN, error) {
 	defaults, err := Defaults(dsn.DefaultsFile)
 	if err != nil {
 		return dsn, err
@@ -100,17 +140,68 @@ func (dsn DSN) AutoDetect() (DSN, error) {
 		if defaults.Socket != "" {
 			dsn.Socket = defaults.Socket
 		} else {
-			if socket, err := GetSocketFromProcessLists(); err != nil {
+			candidates, err := DetectSockets(DefaultSocketDetectors(dsn.DefaultsFile))
+			if err != nil {
+				return dsn, err
+			}
+			if selector == nil {
+				selector = firstSocket
+			}
+			chosen, err := selector(candidates)
+			if err != nil {
 				return dsn, err
-			} else {
-				dsn.Socket = socket
 			}
+			dsn.Socket = chosen.Socket
 		}
 	}
 
 	return dsn, nil
 }
 
+// AutoDetectAll is like AutoDetect, but instead of resolving to a single
+// socket it returns one DSN per candidate found on the host. This is for
+// multi-instance hosts, where picking "the" socket is meaningless and every
+// running mysqld should be considered.
+func (dsn DSN) AutoDetectAll() ([]DSN, error) {
+	defaults, err := Defaults(dsn.DefaultsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	base := dsn
+	if base.Username == "" {
+		if defaults.Username != "" {
+			base.Username = defaults.Username
+		} else {
+			base.Username = os.Getenv("USER")
+			if base.Username == "" {
+				base.Username = "root"
+			}
+		}
+	}
+	if base.Password == "" && defaults.Password != "" {
+		base.Password = defaults.Password
+	}
+
+	if defaults.Socket != "" {
+		base.Socket = defaults.Socket
+		return []DSN{base}, nil
+	}
+
+	candidates, err := DetectSockets(DefaultSocketDetectors(dsn.DefaultsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	dsns := make([]DSN, len(candidates))
+	for i, c := range candidates {
+		d := base
+		d.Socket = c.Socket
+		dsns[i] = d
+	}
+	return dsns, nil
+}
+
 func Defaults(defaultsFile string) (DSN, error) {
 	versionParams := [][]string{
 		[]string{"-s", "client"},
@@ -139,14 +230,26 @@ func Defaults(defaultsFile string) (DSN, error) {
 func (dsn DSN) String() string {
 	dsnString := ""
 
-	// Socket takes priority if set and protocol isn't tcp.
-	if dsn.Socket != "" && dsn.Protocol != "tcp" {
+	switch {
+	case dsn.Socket != "" && dsn.Protocol != "tcp":
+		// Socket takes priority if set and protocol isn't tcp.
 		dsnString = fmt.Sprintf("%s:%s@unix(%s)",
-			dsn.Username,
-			dsn.Password,
+			url.QueryEscape(dsn.Username),
+			url.QueryEscape(dsn.Password),
 			dsn.Socket,
 		)
-	} else {
+	case dsn.Protocol != "" && dsn.Protocol != "tcp":
+		// cloudsql and any other non-tcp, non-unix protocol carry their
+		// address as an opaque string (e.g. "project:region:instance"),
+		// stored in Hostname verbatim by Parse rather than split into a
+		// host:port pair.
+		dsnString = fmt.Sprintf("%s:%s@%s(%s)",
+			url.QueryEscape(dsn.Username),
+			url.QueryEscape(dsn.Password),
+			dsn.Protocol,
+			dsn.Hostname,
+		)
+	default:
 		if dsn.Hostname == "" {
 			dsn.Hostname = "localhost"
 		}
@@ -154,23 +257,55 @@ func (dsn DSN) String() string {
 			dsn.Port = "3306"
 		}
 		dsnString = fmt.Sprintf("%s:%s@tcp(%s:%s)",
-			dsn.Username,
-			dsn.Password,
-			dsn.Hostname,
+			url.QueryEscape(dsn.Username),
+			url.QueryEscape(dsn.Password),
+			bracketHost(dsn.Hostname),
 			dsn.Port,
 		)
 	}
 
 	dsnString += "/" + dsn.DefaultDb
 
-	params := strings.Join(dsn.Params, "&")
-	if params != "" {
-		dsnString += "?" + params
+	params := dsn.paramStrings()
+	if len(params) > 0 {
+		dsnString += "?" + strings.Join(params, "&")
 	}
 
 	return dsnString
 }
 
+// bracketHost wraps host in "[...]" if it's an IPv6 literal (i.e. contains
+// a colon), matching the driver grammar splitHostPort expects it back in
+// as.
+func bracketHost(host string) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// paramStrings renders the typed param fields (tls, timeouts,
+// charset/collation, maxAllowedPacket) back into "key=value" form, followed
+// by whatever untyped params Parse didn't recognize. This is the inverse of
+// applyParam, and together with Parse makes String round-trip losslessly.
+func (dsn DSN) paramStrings() []string {
+	var params []string
+	add := func(key, value string) {
+		if value != "" {
+			params = append(params, key+"="+url.QueryEscape(value))
+		}
+	}
+	add("tls", dsn.TLSConfig)
+	add("readTimeout", dsn.ReadTimeout)
+	add("writeTimeout", dsn.WriteTimeout)
+	add("timeout", dsn.Timeout)
+	add("charset", dsn.Charset)
+	add("collation", dsn.Collation)
+	add("maxAllowedPacket", dsn.MaxAllowedPacket)
+	params = append(params, dsn.Params...)
+	return params
+}
+
 func (dsn DSN) Verify() error {
 	// Open connection to MySQL but...
 	db, err := sql.Open("mysql", dsn.String())
@@ -187,19 +322,21 @@ func (dsn DSN) Verify() error {
 	return nil
 }
 
+// HidePassword redacts the password in a DSN string, replacing it with
+// HiddenPassword. It's built on Parse so it handles passwords containing
+// "@", ":", or "/" correctly, unlike a naive string split.
 func HidePassword(dsn string) string {
-	dsn = strings.TrimRight(strings.Split(dsn, "?")[0], "/")
-	if strings.Index(dsn, "@") > 0 {
-		dsnParts := strings.Split(dsn, "@")
-		userPart := dsnParts[0]
-		hostPart := ""
-		if len(dsnParts) > 1 {
-			hostPart = dsnParts[len(dsnParts)-1]
-		}
-		userPasswordParts := strings.Split(userPart, ":")
-		dsn = fmt.Sprintf("%s:***@%s", userPasswordParts[0], hostPart)
+	parsed, err := Parse(dsn)
+	if err != nil {
+		// Not a DSN we can parse; return it unchanged rather than mangling
+		// something that might not even contain a password.
+		return dsn
 	}
-	return dsn
+	if parsed.Password == "" {
+		return dsn
+	}
+	parsed.Password = HiddenPassword
+	return parsed.String()
 }
 
 // GetSocketFromProcessLists will loop through the list of PIDs until it finds a process
@@ -207,46 +344,22 @@ func HidePassword(dsn string) string {
 // connections for that process.
 // Warning: this function returns the socket for the FIRST mysqld process it founds.
 // If there are more than one MySQL instance, only the first one will be detected.
+//
+// Deprecated: use DetectSockets with ProcSocketDetector (or AutoDetect,
+// which already does this) for multi-instance-aware detection.
 func GetSocketFromProcessLists() (string, error) {
-	pids, err := process.Pids()
+	candidates, err := (ProcSocketDetector{}).Detect()
 	if err != nil {
-		return "", errors.Wrap(err, "Cannot get the list of PIDs")
+		return "", err
 	}
-	sockets := []string{}
-	for _, pid := range pids {
-		proc, err := process.NewProcess(pid)
-		if err != nil {
-			continue
-		}
-		procName, err := proc.Name()
-		if err != nil {
-			continue
-		}
-		if procName != "mysqld" {
-			continue
-		}
-		cons, err := net.ConnectionsPid("unix", pid)
-		if err != nil {
-			return "", errors.Wrapf(err, "Cannot get network connections for PID %d", pid)
-		}
-		for i := range cons {
-			socket := cons[i].Laddr.IP
-			if strings.HasPrefix(socket, "->") {
-				continue
-			}
-			if strings.HasSuffix(socket, "/mysqlx.sock") {
-				continue
-			}
-			sockets = append(sockets, socket)
-		}
+	if len(candidates) == 0 {
+		return "", ErrNoSocket
 	}
-	if len(sockets) > 1 {
-		log.Println("Multiple sockets detected, choosing first one:", strings.Join(sockets, ", "))
-	}
-	if len(sockets) > 0 {
-		return sockets[0], nil
+	chosen, err := firstSocket(candidates)
+	if err != nil {
+		return "", err
 	}
-	return "", ErrNoSocket
+	return chosen.Socket, nil
 }
 
 func ParseMySQLDefaults(output string) DSN {
@@ -0,0 +1,165 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package dsn
+
+import (
+	"crypto/tls"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidDSN is returned by Parse when the input doesn't match the
+// go-sql-driver/mysql DSN grammar:
+//
+//	[username[:password]@][protocol[(address)]]/dbname[?param1=value1&...]
+var ErrInvalidDSN = errors.New("invalid DSN")
+
+var dsnRe = regexp.MustCompile(`^(?:([^:@]*)(?::([^@]*))?@)?(?:([a-z][a-z0-9]*)(?:\(([^)]*)\))?)?/([^?]*)(?:\?(.*))?$`)
+
+// Parse parses a go-sql-driver/mysql style DSN string into a DSN. It accepts
+// the full driver grammar, including unix and tcp protocols, cloudsql
+// proxies, IPv6-bracketed hosts, and URL-escaped usernames/passwords, and is
+// the inverse of DSN.String: for any DSN value d, Parse(d.String()) round-trips
+// back to an equivalent d (modulo field defaults String() fills in).
+func Parse(s string) (DSN, error) {
+	m := dsnRe.FindStringSubmatch(s)
+	if m == nil {
+		return DSN{}, errors.Wrapf(ErrInvalidDSN, "%q", s)
+	}
+
+	rawUsername, rawPassword, protocol, address, dbname, rawParams := m[1], m[2], m[3], m[4], m[5], m[6]
+
+	username, err := url.QueryUnescape(rawUsername)
+	if err != nil {
+		return DSN{}, errors.Wrapf(err, "invalid username encoding in %q", s)
+	}
+
+	password, err := url.QueryUnescape(rawPassword)
+	if err != nil {
+		return DSN{}, errors.Wrapf(err, "invalid password encoding in %q", s)
+	}
+
+	dsn := DSN{
+		Username:  username,
+		Password:  password,
+		Protocol:  protocol,
+		DefaultDb: dbname,
+	}
+
+	switch protocol {
+	case "", "tcp":
+		dsn.Protocol = "tcp"
+		dsn.Hostname, dsn.Port = splitHostPort(address)
+	case "unix":
+		dsn.Socket = address
+	case "cloudsql":
+		dsn.Protocol = "cloudsql"
+		dsn.Hostname = address
+	default:
+		dsn.Hostname = address
+	}
+
+	if rawParams != "" {
+		for _, param := range strings.Split(rawParams, "&") {
+			recognized, err := dsn.applyParam(param)
+			if err != nil {
+				return DSN{}, errors.Wrapf(err, "invalid param in %q", s)
+			}
+			if !recognized {
+				dsn.Params = append(dsn.Params, param)
+			}
+		}
+	}
+
+	return dsn, nil
+}
+
+// splitHostPort splits "host:port" into its parts, tolerating IPv6
+// addresses bracketed as "[::1]:3306" and bare hosts with no port.
+func splitHostPort(address string) (host, port string) {
+	if address == "" {
+		return "", ""
+	}
+	if strings.HasPrefix(address, "[") {
+		if i := strings.Index(address, "]"); i >= 0 {
+			host = address[1:i]
+			rest := address[i+1:]
+			if strings.HasPrefix(rest, ":") {
+				port = rest[1:]
+			}
+			return host, port
+		}
+	}
+	if i := strings.LastIndex(address, ":"); i >= 0 {
+		return address[:i], address[i+1:]
+	}
+	return address, ""
+}
+
+// applyParam recognizes the handful of params that get promoted to typed
+// DSN fields (tls, timeouts, charset/collation, maxAllowedPacket) and
+// reports whether it did; anything else is left for the driver to
+// interpret and is kept verbatim in dsn.Params.
+func (dsn *DSN) applyParam(param string) (recognized bool, err error) {
+	kv := strings.SplitN(param, "=", 2)
+	if len(kv) != 2 {
+		return false, nil
+	}
+	key, rawValue := kv[0], kv[1]
+	value, err := url.QueryUnescape(rawValue)
+	if err != nil {
+		return false, err
+	}
+
+	switch key {
+	case "tls":
+		dsn.TLSConfig = value
+	case "readTimeout":
+		dsn.ReadTimeout = value
+	case "writeTimeout":
+		dsn.WriteTimeout = value
+	case "timeout":
+		dsn.Timeout = value
+	case "charset":
+		dsn.Charset = value
+	case "collation":
+		dsn.Collation = value
+	case "maxAllowedPacket":
+		dsn.MaxAllowedPacket = value
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// RegisterTLSConfig registers a custom tls.Config under name so that a DSN
+// built with TLSConfig: name (i.e. a "tls=name" param) resolves to it. This
+// proxies to mysql.RegisterTLSConfig in go-sql-driver/mysql; Verify and any
+// sql.Open("mysql", dsn.String()) caller rely on the driver having seen this
+// registration before the DSN is used.
+func RegisterTLSConfig(name string, cfg *tls.Config) error {
+	switch name {
+	case "", "true", "false", "skip-verify", "preferred":
+		return errors.Errorf("tls config name %q is reserved", name)
+	}
+	return mysql.RegisterTLSConfig(name, cfg)
+}
@@ -0,0 +1,148 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package dsn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want DSN
+	}{
+		{
+			name: "tcp with params",
+			in:   "user:pass@tcp(127.0.0.1:3306)/mydb?charset=utf8",
+			want: DSN{
+				Username:  "user",
+				Password:  "pass",
+				Protocol:  "tcp",
+				Hostname:  "127.0.0.1",
+				Port:      "3306",
+				DefaultDb: "mydb",
+				Charset:   "utf8",
+			},
+		},
+		{
+			name: "unix socket",
+			in:   "root:@unix(/var/run/mysqld/mysqld.sock)/",
+			want: DSN{
+				Username: "root",
+				Protocol: "unix",
+				Socket:   "/var/run/mysqld/mysqld.sock",
+			},
+		},
+		{
+			name: "no auth, bare host",
+			in:   "/mydb",
+			want: DSN{
+				Protocol:  "tcp",
+				DefaultDb: "mydb",
+			},
+		},
+		{
+			name: "unrecognized param preserved verbatim",
+			in:   "user:pass@tcp(host:3306)/db?allowOldPasswords=true",
+			want: DSN{
+				Username:  "user",
+				Password:  "pass",
+				Protocol:  "tcp",
+				Hostname:  "host",
+				Port:      "3306",
+				DefaultDb: "db",
+				Params:    []string{"allowOldPasswords=true"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStringPreservesNonTCPProtocolAndIPv6(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "cloudsql protocol keyword and address preserved",
+			in:   "user:pass@cloudsql(proj:region:inst)/db",
+			want: "user:pass@cloudsql(proj:region:inst)/db",
+		},
+		{
+			name: "IPv6 host re-bracketed",
+			in:   "user:pass@tcp([::1]:3306)/db",
+			want: "user:pass@tcp([::1]:3306)/db",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := Parse(tt.in)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.in, err)
+			}
+			if got := dsn.String(); got != tt.want {
+				t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse("not a dsn"); err == nil {
+		t.Fatal("Parse(\"not a dsn\") should have returned an error")
+	}
+}
+
+// TestParseStringRoundTrip guards against regressions where special
+// characters in the username or password aren't escaped symmetrically by
+// String and Parse.
+func TestParseStringRoundTrip(t *testing.T) {
+	tests := []DSN{
+		{Username: "user", Password: "pass", Protocol: "tcp", Hostname: "127.0.0.1", Port: "3306", DefaultDb: "db"},
+		{Username: "user", Password: "p@ss:w/ord", Protocol: "tcp", Hostname: "127.0.0.1", Port: "3306", DefaultDb: "db"},
+		{Username: "us:er", Password: "p@ss", Protocol: "unix", Socket: "/tmp/mysql.sock", DefaultDb: "db"},
+		{Username: "user", Password: "pass", Protocol: "tcp", Hostname: "127.0.0.1", Port: "3306", DefaultDb: "db", Charset: "a&b=c"},
+		{Username: "user", Password: "pass", Protocol: "tcp", Hostname: "::1", Port: "3306", DefaultDb: "db"},
+		{Username: "user", Password: "pass", Protocol: "cloudsql", Hostname: "proj:region:inst", DefaultDb: "db"},
+	}
+
+	for _, dsn := range tests {
+		s := dsn.String()
+		got, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", s, err)
+		}
+		if !reflect.DeepEqual(got, dsn) {
+			t.Fatalf("round trip mismatch: String() = %q, Parse gave %+v, want %+v", s, got, dsn)
+		}
+	}
+}
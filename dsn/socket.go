@@ -0,0 +1,253 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package dsn
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// SocketCandidate is one MySQL unix socket found by a SocketDetector, along
+// with whatever metadata the detector was able to gather about the instance
+// that owns it.
+type SocketCandidate struct {
+	Socket  string
+	PID     int32    // 0 if unknown
+	Args    []string // mysqld command-line args, if known
+	Datadir string   // resolved --datadir, if known
+}
+
+// SocketDetector finds candidate MySQL unix sockets on the local host.
+// Detect must not return an error just because it found nothing; it should
+// return (nil, nil) in that case. An error means the detection method itself
+// failed (e.g. the required tool isn't installed).
+type SocketDetector interface {
+	Detect() ([]SocketCandidate, error)
+}
+
+// ProcSocketDetector finds sockets by scanning /proc (via gopsutil) for
+// mysqld processes and inspecting their open unix connections. This is the
+// original GetSocketFromProcessLists behavior, extended to report every
+// mysqld instance it finds instead of just the first.
+type ProcSocketDetector struct{}
+
+func (d ProcSocketDetector) Detect() ([]SocketCandidate, error) {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot get the list of PIDs")
+	}
+
+	var candidates []SocketCandidate
+	for _, pid := range pids {
+		proc, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		procName, err := proc.Name()
+		if err != nil {
+			continue
+		}
+		if procName != "mysqld" {
+			continue
+		}
+
+		args, _ := proc.CmdlineSlice()
+		datadir := datadirFromArgs(args)
+
+		cons, err := net.ConnectionsPid("unix", pid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot get network connections for PID %d", pid)
+		}
+		for i := range cons {
+			socket := cons[i].Laddr.IP
+			if strings.HasPrefix(socket, "->") {
+				continue
+			}
+			if strings.HasSuffix(socket, "/mysqlx.sock") {
+				continue
+			}
+			candidates = append(candidates, SocketCandidate{
+				Socket:  socket,
+				PID:     pid,
+				Args:    args,
+				Datadir: datadir,
+			})
+		}
+	}
+	return candidates, nil
+}
+
+var datadirArgRe = regexp.MustCompile(`^--datadir=(.+)$`)
+
+// datadirFromArgs picks out --datadir=... from a mysqld command line.
+func datadirFromArgs(args []string) string {
+	for _, arg := range args {
+		if m := datadirArgRe.FindStringSubmatch(arg); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// NetstatSocketDetector finds sockets by shelling out to `ss -xlp` (and
+// falling back to `lsof -U` if ss isn't available). This covers platforms
+// and containers where gopsutil can't enumerate unix sockets, e.g. because
+// /proc/net/unix is hidden or the process netns differs from ours.
+type NetstatSocketDetector struct{}
+
+var (
+	// Real `ss -xlp` output puts the inode and Peer-Address:Port columns
+	// between the socket path and the process column, e.g.:
+	//   u_str  LISTEN  0  128  /var/run/mysqld/mysqld.sock 23197  * 0  users:(("mysqld",pid=1234,fd=33))
+	// so the match can't assume "users:((" immediately follows the path.
+	ssSocketRe   = regexp.MustCompile(`(\S+\.sock)\s.*?users:\(\("mysqld"`)
+	lsofSocketRe = regexp.MustCompile(`^mysqld\s+(\d+)\s.*\s(\S+\.sock)$`)
+)
+
+func (d NetstatSocketDetector) Detect() ([]SocketCandidate, error) {
+	if out, err := exec.Command("ss", "-xlp").Output(); err == nil {
+		var candidates []SocketCandidate
+		for _, line := range strings.Split(string(out), "\n") {
+			if m := ssSocketRe.FindStringSubmatch(line); m != nil {
+				candidates = append(candidates, SocketCandidate{Socket: m[1]})
+			}
+		}
+		return candidates, nil
+	}
+
+	out, err := exec.Command("lsof", "-U").Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot run ss or lsof to enumerate unix sockets")
+	}
+	var candidates []SocketCandidate
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "mysqld" {
+			continue
+		}
+		if m := lsofSocketRe.FindStringSubmatch(line); m != nil {
+			pid, _ := strconv.ParseInt(m[1], 10, 32)
+			candidates = append(candidates, SocketCandidate{Socket: m[2], PID: int32(pid)})
+		}
+	}
+	return candidates, nil
+}
+
+// FilesystemDetector probes a fixed list of paths where distributions
+// commonly place the MySQL socket. It's the last-resort detector: it
+// doesn't know which paths are actually in use, only which ones exist.
+type FilesystemDetector struct {
+	// Paths to probe. If empty, CommonSocketPaths is used.
+	Paths []string
+}
+
+// CommonSocketPaths are well-known locations for mysqld.sock across Linux
+// distributions and common Docker images.
+var CommonSocketPaths = []string{
+	"/var/run/mysqld/mysqld.sock",
+	"/var/lib/mysql/mysql.sock",
+	"/tmp/mysql.sock",
+}
+
+func (d FilesystemDetector) Detect() ([]SocketCandidate, error) {
+	paths := d.Paths
+	if len(paths) == 0 {
+		paths = CommonSocketPaths
+	}
+	var candidates []SocketCandidate
+	for _, path := range paths {
+		if fileExists(path) {
+			candidates = append(candidates, SocketCandidate{Socket: path})
+		}
+	}
+	return candidates, nil
+}
+
+// MyCnfDetector reads the [mysqld] section of the active my.cnf (via
+// my_print_defaults, the same tool Defaults() uses for the client section)
+// to find the socket the server itself was configured with.
+type MyCnfDetector struct {
+	DefaultsFile string
+}
+
+func (d MyCnfDetector) Detect() ([]SocketCandidate, error) {
+	params := []string{"mysqld"}
+	if d.DefaultsFile != "" {
+		params = append([]string{"--defaults-file=" + d.DefaultsFile}, params...)
+	}
+
+	output, err := exec.Command("my_print_defaults", params...).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot run my_print_defaults for [mysqld] section")
+	}
+
+	socketRe := regexp.MustCompile(`--socket=(.*)`)
+	for _, line := range strings.Split(string(output), "\n") {
+		if m := socketRe.FindStringSubmatch(line); m != nil {
+			return []SocketCandidate{{Socket: m[len(m)-1]}}, nil
+		}
+	}
+	return nil, nil
+}
+
+// DefaultSocketDetectors returns the detectors AutoDetect uses, in the order
+// they're tried: /proc scanning first (richest metadata), then netstat-style
+// tools, then my.cnf, then a plain filesystem probe as the last resort.
+func DefaultSocketDetectors(defaultsFile string) []SocketDetector {
+	return []SocketDetector{
+		ProcSocketDetector{},
+		NetstatSocketDetector{},
+		MyCnfDetector{DefaultsFile: defaultsFile},
+		FilesystemDetector{},
+	}
+}
+
+// DetectSockets runs each detector in turn, returning the candidates from
+// the first one that finds any. Detectors that error are skipped rather
+// than failing the whole search, since a missing tool (ss, lsof,
+// my_print_defaults) on one platform shouldn't prevent falling back to the
+// next method.
+func DetectSockets(detectors []SocketDetector) ([]SocketCandidate, error) {
+	var lastErr error
+	for _, detector := range detectors {
+		candidates, err := detector.Detect()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(candidates) > 0 {
+			return candidates, nil
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrNoSocket
+}
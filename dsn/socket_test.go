@@ -0,0 +1,66 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package dsn
+
+import "testing"
+
+func TestSsSocketRe(t *testing.T) {
+	// A realistic `ss -xlp` line: the socket path is followed by an inode
+	// number and a "Peer Address:Port" placeholder column before the
+	// process column, not immediately by "users:((".
+	line := `u_str  LISTEN     0      128      /var/run/mysqld/mysqld.sock 23197        * 0                    users:(("mysqld",pid=1234,fd=33))`
+
+	m := ssSocketRe.FindStringSubmatch(line)
+	if m == nil {
+		t.Fatalf("ssSocketRe did not match a realistic ss -xlp line: %q", line)
+	}
+	if m[1] != "/var/run/mysqld/mysqld.sock" {
+		t.Errorf("ssSocketRe captured %q, want /var/run/mysqld/mysqld.sock", m[1])
+	}
+}
+
+func TestSsSocketReIgnoresOtherProcesses(t *testing.T) {
+	line := `u_str  LISTEN     0      128      /tmp/other.sock 23198        * 0                    users:(("nginx",pid=5678,fd=12))`
+	if m := ssSocketRe.FindStringSubmatch(line); m != nil {
+		t.Errorf("ssSocketRe matched a non-mysqld line: %q", line)
+	}
+}
+
+func TestLsofSocketRe(t *testing.T) {
+	line := `mysqld    1234 mysql   33u  unix 0x0000000000000000      0t0      12345 /var/run/mysqld/mysqld.sock`
+	m := lsofSocketRe.FindStringSubmatch(line)
+	if m == nil {
+		t.Fatalf("lsofSocketRe did not match a realistic lsof -U line: %q", line)
+	}
+	if m[1] != "1234" {
+		t.Errorf("lsofSocketRe captured PID %q, want 1234", m[1])
+	}
+	if m[2] != "/var/run/mysqld/mysqld.sock" {
+		t.Errorf("lsofSocketRe captured socket %q, want /var/run/mysqld/mysqld.sock", m[2])
+	}
+}
+
+func TestDatadirFromArgs(t *testing.T) {
+	args := []string{"/usr/sbin/mysqld", "--datadir=/var/lib/mysql", "--socket=/var/run/mysqld/mysqld.sock"}
+	if got := datadirFromArgs(args); got != "/var/lib/mysql" {
+		t.Errorf("datadirFromArgs(%v) = %q, want /var/lib/mysql", args, got)
+	}
+	if got := datadirFromArgs([]string{"/usr/sbin/mysqld"}); got != "" {
+		t.Errorf("datadirFromArgs with no --datadir = %q, want empty", got)
+	}
+}
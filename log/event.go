@@ -0,0 +1,63 @@
+/*
+   Copyright (c) 2014, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+// Package log provides the format-agnostic representation of a parsed
+// MySQL log event, shared by the slow log, general log, and binlog
+// parsers.
+package log
+
+// Event is a parsed query event: one entry in a slow log, general log, or
+// similar.
+type Event struct {
+	Offset    uint64 // byte offset in log file
+	Ts        string // raw timestamp from the log
+	Admin     bool   // true if Query is actually an admin command
+	Query     string
+	User      string
+	Host      string
+	Db        string
+	RateType  string // Log_slow_rate_type
+	RateLimit byte   // Log_slow_rate_limit
+	// --
+	// LastInsertID and InsertID come from a `SET last_insert_id=N,
+	// insert_id=N;` line preceding the query; they have no header
+	// equivalent, so they're captured as dedicated fields rather than
+	// folded into Metrics below.
+	LastInsertID uint64
+	InsertID     uint64
+	// --
+	// Fingerprint is the query's abstracted form (literals and whitespace
+	// normalized away), set by sink.Pipeline's fingerprinting transform.
+	// It's empty unless that transform ran.
+	Fingerprint string
+	// --
+	Metrics Metrics
+}
+
+// NewEvent returns a new, empty Event with Metrics.Extra initialized so
+// callers can assign into it without a nil check.
+func NewEvent() *Event {
+	return &Event{
+		Metrics: NewMetrics(),
+	}
+}
+
+// Legacy returns e's metrics as the stringly-typed maps Event used before
+// Metrics existed, for callers that haven't moved to the typed fields yet.
+func (e *Event) Legacy() (timeMetrics map[string]float32, numberMetrics map[string]uint64, boolMetrics map[string]bool) {
+	return e.Metrics.legacy()
+}
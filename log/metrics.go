@@ -0,0 +1,176 @@
+/*
+   Copyright (c) 2014, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package log
+
+// ValueKind identifies which field of a MetricValue holds the parsed
+// value.
+type ValueKind uint8
+
+const (
+	UnknownValue ValueKind = iota
+	TimeValue
+	IntValue
+	BoolValue
+)
+
+// MetricValue is a metric Metrics has no named field for. Metrics.Extra
+// holds one per such key, carrying both the metric's raw log text and its
+// parsed value, since a consumer that doesn't recognize the key by name
+// may still want the number.
+type MetricValue struct {
+	Raw  string
+	Kind ValueKind
+
+	Time float64 // valid if Kind == TimeValue, in seconds
+	Int  uint64  // valid if Kind == IntValue
+	Bool bool    // valid if Kind == BoolValue
+}
+
+// Metrics is the typed set of performance counters a log event carries.
+// Times are float64 seconds; float32 loses microsecond precision above
+// roughly 16s, which matters for Query_time on a genuinely slow query.
+// Fields are named for the metric they hold rather than its raw log
+// token; see the metricTable in package slow for that mapping.
+type Metrics struct {
+	QueryTime float64
+	LockTime  float64
+
+	RowsSent     uint64
+	RowsExamined uint64
+	RowsAffected uint64
+	RowsRead     uint64
+	BytesSent    uint64
+
+	TmpTables     uint64
+	TmpDiskTables uint64
+	TmpTableSizes uint64
+
+	MergePasses uint64
+
+	// InnoDB* fields are Percona Server's Log_slow_verbosity=innodb extras;
+	// they're absent from a log event entirely (rather than just zero)
+	// unless verbosity includes "innodb".
+	InnoDBIOROps        uint64
+	InnoDBIORBytes      uint64
+	InnoDBIORWait       float64
+	InnoDBRecLockWait   float64
+	InnoDBQueueWait     float64
+	InnoDBPagesDistinct uint64
+
+	FullScan       bool
+	FullJoin       bool
+	TmpTable       bool
+	TmpTableOnDisk bool
+	Filesort       bool
+	FilesortOnDisk bool
+	QCHit          bool
+
+	// Extra holds every metric the log exposed that Metrics has no named
+	// field for, keyed by its raw log token (e.g. "Last_errno").
+	Extra map[string]MetricValue
+
+	// present tracks which named fields above a log event actually carried,
+	// keyed by the same raw log token Extra would use (e.g. "InnoDB_IO_r_ops").
+	// Unlike Extra, named fields always have a value in the struct - this is
+	// what lets legacy tell a metric that's absent from the log apart from
+	// one that's legitimately zero.
+	present map[string]struct{}
+}
+
+// NewMetrics returns a Metrics with Extra initialized so callers can
+// assign into it without a nil check.
+func NewMetrics() Metrics {
+	return Metrics{
+		Extra:   make(map[string]MetricValue),
+		present: make(map[string]struct{}),
+	}
+}
+
+// MarkPresent records that the named field was actually set from the log,
+// so legacy can report it; callers are the metricTable setters in package
+// slow, keyed by the same raw log token metricTable dispatches on.
+func (m *Metrics) MarkPresent(name string) {
+	m.present[name] = struct{}{}
+}
+
+// legacy converts m back into the stringly-typed TimeMetrics/NumberMetrics/
+// BoolMetrics maps Event exposed before Metrics existed. A named field is
+// only added to its map if the log actually carried it - see the InnoDB*
+// comment above - matching how the old maps never held keys for metrics a
+// given log event lacked.
+func (m Metrics) legacy() (timeMetrics map[string]float32, numberMetrics map[string]uint64, boolMetrics map[string]bool) {
+	timeMetrics = make(map[string]float32)
+	numberMetrics = make(map[string]uint64)
+	boolMetrics = make(map[string]bool)
+
+	addTime := func(name string, v float64) {
+		if _, ok := m.present[name]; ok {
+			timeMetrics[name] = float32(v)
+		}
+	}
+	addNumber := func(name string, v uint64) {
+		if _, ok := m.present[name]; ok {
+			numberMetrics[name] = v
+		}
+	}
+	addBool := func(name string, v bool) {
+		if _, ok := m.present[name]; ok {
+			boolMetrics[name] = v
+		}
+	}
+
+	addTime("Query_time", m.QueryTime)
+	addTime("Lock_time", m.LockTime)
+	addTime("InnoDB_IO_r_wait", m.InnoDBIORWait)
+	addTime("InnoDB_rec_lock_wait", m.InnoDBRecLockWait)
+	addTime("InnoDB_queue_wait", m.InnoDBQueueWait)
+
+	addNumber("Rows_sent", m.RowsSent)
+	addNumber("Rows_examined", m.RowsExamined)
+	addNumber("Rows_affected", m.RowsAffected)
+	addNumber("Rows_read", m.RowsRead)
+	addNumber("Bytes_sent", m.BytesSent)
+	addNumber("Tmp_tables", m.TmpTables)
+	addNumber("Tmp_disk_tables", m.TmpDiskTables)
+	addNumber("Tmp_table_sizes", m.TmpTableSizes)
+	addNumber("Merge_passes", m.MergePasses)
+	addNumber("InnoDB_IO_r_ops", m.InnoDBIOROps)
+	addNumber("InnoDB_IO_r_bytes", m.InnoDBIORBytes)
+	addNumber("InnoDB_pages_distinct", m.InnoDBPagesDistinct)
+
+	addBool("Full_scan", m.FullScan)
+	addBool("Full_join", m.FullJoin)
+	addBool("Tmp_table", m.TmpTable)
+	addBool("Tmp_table_on_disk", m.TmpTableOnDisk)
+	addBool("Filesort", m.Filesort)
+	addBool("Filesort_on_disk", m.FilesortOnDisk)
+	addBool("QC_Hit", m.QCHit)
+
+	for name, v := range m.Extra {
+		switch v.Kind {
+		case TimeValue:
+			timeMetrics[name] = float32(v.Time)
+		case IntValue:
+			numberMetrics[name] = v.Int
+		case BoolValue:
+			boolMetrics[name] = v.Bool
+		}
+	}
+
+	return timeMetrics, numberMetrics, boolMetrics
+}
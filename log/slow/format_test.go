@@ -0,0 +1,77 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package slow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/percona/go-mysql/log"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		line string
+		want Format
+	}{
+		{`{"ts":"2016-01-01T00:00:00.000000Z"}`, JSONFormat},
+		{`  {"ts":"2016-01-01T00:00:00.000000Z"}`, JSONFormat},
+		{"# Time: 160101  0:00:00", TextFormat},
+		{"SELECT 1", UnknownFormat},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.line); got != tt.want {
+			t.Errorf("DetectFormat(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseJSONResumesFromStartOffset(t *testing.T) {
+	const startOffset = 100
+	events := `{"ts":"2016-01-01T00:00:00.000000Z","query":"select 1"}
+{"ts":"2016-01-01T00:00:01.000000Z","query":"select 2"}
+`
+	p := newReaderParser(log.Options{StartOffset: startOffset})
+
+	var offsets []uint64
+	p.reportOffset = func(offset uint64) { offsets = append(offsets, offset) }
+
+	done := make(chan error, 1)
+	go func() { done <- p.parseJSON(strings.NewReader(events)) }()
+
+	first := <-p.eventChan
+	second := <-p.eventChan
+	if first.Query != "select 1" || second.Query != "select 2" {
+		t.Fatalf("unexpected events: %q, %q", first.Query, second.Query)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("parseJSON returned error: %v", err)
+	}
+
+	if len(offsets) != 2 {
+		t.Fatalf("got %d reported offsets, want 2", len(offsets))
+	}
+	for _, off := range offsets {
+		if off <= startOffset {
+			t.Errorf("reported offset %d should be greater than StartOffset %d", off, startOffset)
+		}
+	}
+	if offsets[1] <= offsets[0] {
+		t.Errorf("offsets should be monotonically increasing, got %v", offsets)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+   Copyright (c) 2014, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package slow
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/percona/go-mysql/log"
+)
+
+// metricTable maps a metric's raw log token (e.g. "Query_time") to the
+// Metrics field it belongs in. parseHeader dispatches through this rather
+// than sniffing the token's suffix, so a metric like Bytes_sent - not
+// *_time/*_wait, but also not obviously an integer from its name alone -
+// is classified by an explicit entry instead of a guess that happens to be
+// right today.
+var metricTable = map[string]func(m *log.Metrics, raw string){
+	"Query_time": func(m *log.Metrics, raw string) { m.QueryTime = parseSeconds(raw) },
+	"Lock_time":  func(m *log.Metrics, raw string) { m.LockTime = parseSeconds(raw) },
+
+	"Rows_sent":     func(m *log.Metrics, raw string) { m.RowsSent = parseUint(raw) },
+	"Rows_examined": func(m *log.Metrics, raw string) { m.RowsExamined = parseUint(raw) },
+	"Rows_affected": func(m *log.Metrics, raw string) { m.RowsAffected = parseUint(raw) },
+	"Rows_read":     func(m *log.Metrics, raw string) { m.RowsRead = parseUint(raw) },
+	"Bytes_sent":    func(m *log.Metrics, raw string) { m.BytesSent = parseUint(raw) },
+
+	"Tmp_tables":      func(m *log.Metrics, raw string) { m.TmpTables = parseUint(raw) },
+	"Tmp_disk_tables": func(m *log.Metrics, raw string) { m.TmpDiskTables = parseUint(raw) },
+	"Tmp_table_sizes": func(m *log.Metrics, raw string) { m.TmpTableSizes = parseUint(raw) },
+
+	"Merge_passes": func(m *log.Metrics, raw string) { m.MergePasses = parseUint(raw) },
+
+	// InnoDB_* are Percona Server's Log_slow_verbosity=innodb fields.
+	"InnoDB_IO_r_ops":       func(m *log.Metrics, raw string) { m.InnoDBIOROps = parseUint(raw) },
+	"InnoDB_IO_r_bytes":     func(m *log.Metrics, raw string) { m.InnoDBIORBytes = parseUint(raw) },
+	"InnoDB_IO_r_wait":      func(m *log.Metrics, raw string) { m.InnoDBIORWait = parseSeconds(raw) },
+	"InnoDB_rec_lock_wait":  func(m *log.Metrics, raw string) { m.InnoDBRecLockWait = parseSeconds(raw) },
+	"InnoDB_queue_wait":     func(m *log.Metrics, raw string) { m.InnoDBQueueWait = parseSeconds(raw) },
+	"InnoDB_pages_distinct": func(m *log.Metrics, raw string) { m.InnoDBPagesDistinct = parseUint(raw) },
+
+	"Full_scan":         func(m *log.Metrics, raw string) { m.FullScan = raw == "Yes" },
+	"Full_join":         func(m *log.Metrics, raw string) { m.FullJoin = raw == "Yes" },
+	"Tmp_table":         func(m *log.Metrics, raw string) { m.TmpTable = raw == "Yes" },
+	"Tmp_table_on_disk": func(m *log.Metrics, raw string) { m.TmpTableOnDisk = raw == "Yes" },
+	"Filesort":          func(m *log.Metrics, raw string) { m.Filesort = raw == "Yes" },
+	"Filesort_on_disk":  func(m *log.Metrics, raw string) { m.FilesortOnDisk = raw == "Yes" },
+	"QC_Hit":            func(m *log.Metrics, raw string) { m.QCHit = raw == "Yes" },
+}
+
+func parseSeconds(raw string) float64 {
+	val, _ := strconv.ParseFloat(raw, 64)
+	return val
+}
+
+func parseUint(raw string) uint64 {
+	val, _ := strconv.ParseUint(raw, 10, 64)
+	return val
+}
+
+// setMetric stores a metric's raw value onto event: through metricTable's
+// setter if name is a known metric, otherwise into event.Metrics.Extra,
+// inferring its ValueKind from the raw text the same way the old
+// suffix-sniffing heuristic did. Unknown keys are, by definition, ones
+// metricTable hasn't been taught yet, so that fallback stays a guess.
+func setMetric(event *log.Event, name, raw string) {
+	if setter, ok := metricTable[name]; ok {
+		setter(&event.Metrics, raw)
+		event.Metrics.MarkPresent(name)
+		return
+	}
+
+	mv := log.MetricValue{Raw: raw}
+	switch {
+	case raw == "Yes" || raw == "No":
+		mv.Kind = log.BoolValue
+		mv.Bool = raw == "Yes"
+	case strings.HasSuffix(name, "_time") || strings.HasSuffix(name, "_wait"):
+		mv.Kind = log.TimeValue
+		mv.Time = parseSeconds(raw)
+	default:
+		if val, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			mv.Kind = log.IntValue
+			mv.Int = val
+		}
+	}
+	event.Metrics.Extra[name] = mv
+}
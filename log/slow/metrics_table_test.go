@@ -0,0 +1,107 @@
+/*
+   Copyright (c) 2014, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package slow
+
+import (
+	"testing"
+
+	"github.com/percona/go-mysql/log"
+)
+
+func TestSetMetricKnownFields(t *testing.T) {
+	event := log.NewEvent()
+
+	setMetric(event, "Query_time", "1.500000")
+	setMetric(event, "Rows_examined", "42")
+	setMetric(event, "Merge_passes", "3")
+	setMetric(event, "InnoDB_IO_r_ops", "7")
+	setMetric(event, "InnoDB_IO_r_wait", "0.001200")
+	setMetric(event, "Full_scan", "Yes")
+
+	if event.Metrics.QueryTime != 1.5 {
+		t.Errorf("QueryTime = %v, want 1.5", event.Metrics.QueryTime)
+	}
+	if event.Metrics.RowsExamined != 42 {
+		t.Errorf("RowsExamined = %v, want 42", event.Metrics.RowsExamined)
+	}
+	if event.Metrics.MergePasses != 3 {
+		t.Errorf("MergePasses = %v, want 3", event.Metrics.MergePasses)
+	}
+	if event.Metrics.InnoDBIOROps != 7 {
+		t.Errorf("InnoDBIOROps = %v, want 7", event.Metrics.InnoDBIOROps)
+	}
+	if event.Metrics.InnoDBIORWait != 0.0012 {
+		t.Errorf("InnoDBIORWait = %v, want 0.0012", event.Metrics.InnoDBIORWait)
+	}
+	if !event.Metrics.FullScan {
+		t.Error("FullScan = false, want true")
+	}
+
+	// Known fields must not leak into Extra.
+	for _, name := range []string{"Query_time", "Rows_examined", "Merge_passes", "InnoDB_IO_r_ops", "InnoDB_IO_r_wait", "Full_scan"} {
+		if _, ok := event.Metrics.Extra[name]; ok {
+			t.Errorf("%s unexpectedly present in Extra", name)
+		}
+	}
+}
+
+func TestSetMetricLegacyOmitsAbsentMetrics(t *testing.T) {
+	event := log.NewEvent()
+
+	// No Log_slow_verbosity=innodb extras on this event, so InnoDB_IO_r_ops
+	// keeps its zero value, but setMetric was never called for it.
+	setMetric(event, "Query_time", "1.500000")
+	setMetric(event, "Rows_examined", "42")
+	setMetric(event, "Full_scan", "Yes")
+
+	timeMetrics, numberMetrics, boolMetrics := event.Legacy()
+
+	if _, ok := timeMetrics["InnoDB_IO_r_wait"]; ok {
+		t.Error("InnoDB_IO_r_wait present in Legacy()'s timeMetrics, want absent since it was never set")
+	}
+	if _, ok := numberMetrics["InnoDB_IO_r_ops"]; ok {
+		t.Error("InnoDB_IO_r_ops present in Legacy()'s numberMetrics, want absent since it was never set")
+	}
+	if _, ok := boolMetrics["Tmp_table"]; ok {
+		t.Error("Tmp_table present in Legacy()'s boolMetrics, want absent since it was never set")
+	}
+
+	if got, want := timeMetrics["Query_time"], float32(1.5); got != want {
+		t.Errorf("Query_time = %v, want %v", got, want)
+	}
+	if got, want := numberMetrics["Rows_examined"], uint64(42); got != want {
+		t.Errorf("Rows_examined = %v, want %v", got, want)
+	}
+	if !boolMetrics["Full_scan"] {
+		t.Error("Full_scan = false, want true")
+	}
+}
+
+func TestSetMetricUnknownFallsBackToExtra(t *testing.T) {
+	event := log.NewEvent()
+
+	setMetric(event, "Some_future_metric", "123")
+
+	mv, ok := event.Metrics.Extra["Some_future_metric"]
+	if !ok {
+		t.Fatal("Some_future_metric not found in Extra")
+	}
+	if mv.Kind != log.IntValue || mv.Int != 123 {
+		t.Errorf("Extra[Some_future_metric] = %+v, want IntValue 123", mv)
+	}
+}
@@ -36,21 +36,30 @@ var headerRe = regexp.MustCompile(`^#\s+[A-Z]`)
 var metricsRe = regexp.MustCompile(`(\w+): (\S+|\z)`)
 var adminRe = regexp.MustCompile(`command: (.+)`)
 var setRe = regexp.MustCompile(`SET (?:last_insert_id|insert_id|timestamp)`)
+var setVarRe = regexp.MustCompile(`(?i)(last_insert_id|insert_id|timestamp)=(\d+)`)
+
+// OffsetFunc is called with the parser's current byte offset into its
+// input each time an event is sent. Tailer uses this to build Checkpoints
+// without reaching into the parser's internals.
+type OffsetFunc func(offset uint64)
 
 type SlowLogParser struct {
 	file *os.File
 	opt  log.Options
 	// --
-	stopChan    chan bool
-	eventChan   chan *log.Event
-	inHeader    bool
-	inQuery     bool
-	headerLines uint
-	queryLines  uint64
-	bytesRead   uint64
-	lineOffset  uint64
-	stopped     bool
-	event       *log.Event
+	stopChan     chan bool
+	eventChan    chan *log.Event
+	inHeader     bool
+	inQuery      bool
+	headerLines  uint
+	queryLines   uint64
+	bytesRead    uint64
+	lineOffset   uint64
+	stopped      bool
+	event        *log.Event
+	reportOffset OffsetFunc
+	format       Format
+	gotQueryTime bool
 }
 
 func NewSlowLogParser(file *os.File, opt log.Options) *SlowLogParser {
@@ -72,6 +81,14 @@ func NewSlowLogParser(file *os.File, opt log.Options) *SlowLogParser {
 	return p
 }
 
+// newReaderParser is like NewSlowLogParser but for callers that don't have
+// (or don't want to use) an *os.File, e.g. Tailer replaying a decompressed
+// rotated segment. Such a parser can only be driven via parse(), not Start,
+// since Start needs p.file for seeking and its debug log message.
+func newReaderParser(opt log.Options) *SlowLogParser {
+	return NewSlowLogParser(nil, opt)
+}
+
 func (p *SlowLogParser) EventChan() <-chan *log.Event {
 	return p.eventChan
 }
@@ -99,9 +116,35 @@ func (p *SlowLogParser) Start() error {
 		}
 	}
 
+	return p.parse(p.file, nil)
+}
+
+// parse reads slow log lines from r until EOF or Stop, reporting its
+// progress through reportOffset (which may be nil) as events are sent. It's
+// the engine behind both Start, which drives it over a batch *os.File, and
+// Tailer, which drives it over a reader that blocks for more data instead
+// of returning EOF.
+func (p *SlowLogParser) parse(r io.Reader, reportOffset OffsetFunc) error {
+	p.reportOffset = reportOffset
+
 	defer close(p.eventChan)
 
-	r := bufio.NewReader(p.file)
+	reader := bufio.NewReader(r)
+
+	if p.format == UnknownFormat {
+		format, err := p.detectFormat(reader)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		p.format = format
+	}
+
+	if p.format == JSONFormat {
+		return p.parseJSON(reader)
+	}
 
 SCANNER_LOOP:
 	for !p.stopped {
@@ -112,7 +155,7 @@ SCANNER_LOOP:
 		default:
 		}
 
-		line, err := r.ReadString('\n')
+		line, err := reader.ReadString('\n')
 		if err != nil {
 			if err != io.EOF {
 				return err
@@ -138,10 +181,7 @@ SCANNER_LOOP:
 		//   /usr/local/bin/mysqld, Version: 5.6.15-62.0-tokudb-7.1.0-tokudb-log (binary). started with:
 		//   Tcp port: 3306  Unix socket: /var/lib/mysql/mysql.sock
 		//   Time                 Id Command    Argument
-		if lineLen >= 20 && ((line[0] == '/' && line[lineLen-6:lineLen] == "with:\n") ||
-			(line[0:5] == "Time ") ||
-			(line[0:4] == "Tcp ") ||
-			(line[0:4] == "TCP ")) {
+		if isMetaLine(line) {
 			if p.opt.Debug {
 				l.Println("meta")
 			}
@@ -172,6 +212,46 @@ SCANNER_LOOP:
 	return nil
 }
 
+// isMetaLine reports whether line is one of the non-event lines mysqld
+// writes around the events proper:
+//
+//	/usr/local/bin/mysqld, Version: 5.6.15-62.0-tokudb-7.1.0-tokudb-log (binary). started with:
+//	Tcp port: 3306  Unix socket: /var/lib/mysql/mysql.sock
+//	Time                 Id Command    Argument
+func isMetaLine(line string) bool {
+	lineLen := len(line)
+	return lineLen >= 20 && ((line[0] == '/' && line[lineLen-6:lineLen] == "with:\n") ||
+		(line[0:5] == "Time ") ||
+		(line[0:4] == "Tcp ") ||
+		(line[0:4] == "TCP "))
+}
+
+// detectFormat peeks (without consuming) the first non-meta line of reader
+// to decide which Format the log is in, so parse can dispatch to the right
+// decoder. Only that first non-meta line is examined: it defaults to
+// TextFormat if that line is inconclusive or the peek window holds no
+// non-meta line, since that's always been the only format and still is for
+// the overwhelming majority of logs.
+func (p *SlowLogParser) detectFormat(reader *bufio.Reader) (Format, error) {
+	peeked, err := reader.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return UnknownFormat, err
+	}
+	if len(peeked) == 0 {
+		return UnknownFormat, io.EOF
+	}
+	for _, line := range strings.SplitAfter(string(peeked), "\n") {
+		if line == "" || isMetaLine(line) {
+			continue
+		}
+		if format := DetectFormat(line); format != UnknownFormat {
+			return format, nil
+		}
+		return TextFormat, nil
+	}
+	return TextFormat, nil
+}
+
 // --------------------------------------------------------------------------
 
 func (p *SlowLogParser) parseHeader(line string) {
@@ -221,28 +301,19 @@ func (p *SlowLogParser) parseHeader(line string) {
 		m := metricsRe.FindAllStringSubmatch(line, -1)
 		for _, smv := range m {
 			// [String, Metric, Value], e.g. ["Query_time: 2", "Query_time", "2"]
-			if strings.HasSuffix(smv[1], "_time") || strings.HasSuffix(smv[1], "_wait") {
-				// microsecond value
-				val, _ := strconv.ParseFloat(smv[2], 32)
-				p.event.TimeMetrics[smv[1]] = float32(val)
-			} else if smv[2] == "Yes" || smv[2] == "No" {
-				// boolean value
-				if smv[2] == "Yes" {
-					p.event.BoolMetrics[smv[1]] = true
-				} else {
-					p.event.BoolMetrics[smv[1]] = false
-				}
-			} else if smv[1] == "Schema" {
+			switch smv[1] {
+			case "Schema":
 				p.event.Db = smv[2]
-			} else if smv[1] == "Log_slow_rate_type" {
+			case "Log_slow_rate_type":
 				p.event.RateType = smv[2]
-			} else if smv[1] == "Log_slow_rate_limit" {
+			case "Log_slow_rate_limit":
 				val, _ := strconv.ParseUint(smv[2], 10, 64)
 				p.event.RateLimit = byte(val)
-			} else {
-				// integer value
-				val, _ := strconv.ParseUint(smv[2], 10, 64)
-				p.event.NumberMetrics[smv[1]] = val
+			default:
+				setMetric(p.event, smv[1], smv[2])
+				if smv[1] == "Query_time" {
+					p.gotQueryTime = true
+				}
 			}
 		}
 	}
@@ -278,7 +349,7 @@ func (p *SlowLogParser) parseQuery(line string) {
 		if p.opt.Debug {
 			l.Println("set var")
 		}
-		// @todo ignore or use these lines?
+		p.parseSetLine(line)
 	} else {
 		if p.opt.Debug {
 			l.Println("query")
@@ -292,6 +363,26 @@ func (p *SlowLogParser) parseQuery(line string) {
 	}
 }
 
+// parseSetLine pulls last_insert_id, insert_id, and timestamp out of a
+// `SET ...;` line preceding a query. These are metadata, not part of the
+// query, and they're the only place last_insert_id/insert_id show up at
+// all; timestamp here is also the only source of event.Ts on MySQL 5.7,
+// which dropped the `# Time` header.
+func (p *SlowLogParser) parseSetLine(line string) {
+	for _, m := range setVarRe.FindAllStringSubmatch(line, -1) {
+		switch strings.ToLower(m[1]) {
+		case "timestamp":
+			if p.event.Ts == "" {
+				p.event.Ts = m[2]
+			}
+		case "last_insert_id":
+			p.event.LastInsertID, _ = strconv.ParseUint(m[2], 10, 64)
+		case "insert_id":
+			p.event.InsertID, _ = strconv.ParseUint(m[2], 10, 64)
+		}
+	}
+}
+
 func (p *SlowLogParser) parseAdmin(line string) {
 	if p.opt.Debug {
 		l.Println("admin")
@@ -323,11 +414,12 @@ func (p *SlowLogParser) sendEvent(inHeader bool, inQuery bool) {
 		p.event = log.NewEvent()
 		p.headerLines = 0
 		p.queryLines = 0
+		p.gotQueryTime = false
 		p.inHeader = inHeader
 		p.inQuery = inQuery
 	}()
 
-	if _, ok := p.event.TimeMetrics["Query_time"]; !ok {
+	if !p.gotQueryTime {
 		if p.headerLines == 0 {
 			l.Panicf("No Query_time in event at %d: %#v", p.lineOffset, p.event)
 		}
@@ -342,7 +434,10 @@ func (p *SlowLogParser) sendEvent(inHeader bool, inQuery bool) {
 	// Send the event.  This will block.
 	select {
 	case p.eventChan <- p.event:
+		if p.reportOffset != nil {
+			p.reportOffset(p.bytesRead)
+		}
 	case <-p.stopChan:
 		p.stopped = true
 	}
-}
\ No newline at end of file
+}
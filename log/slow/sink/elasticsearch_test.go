@@ -0,0 +1,65 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/percona/go-mysql/log"
+)
+
+// erroringTransport fakes an Elasticsearch _bulk response that's
+// HTTP-level-successful but application-level an error (e.g. a 429 or a
+// rejected document), the case flushLocked must distinguish from a
+// transport failure.
+type erroringTransport struct{ status int }
+
+func (t erroringTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: t.status,
+		Body:       io.NopCloser(strings.NewReader(`{"error":"rejected"}`)),
+		Header:     make(http.Header),
+	}, nil
+}
+
+func TestFlushLockedPreservesBufferOnBulkError(t *testing.T) {
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Transport: erroringTransport{status: 429}})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	s, err := NewElasticsearchSink(ElasticsearchConfig{Client: client, BulkSize: 1})
+	if err != nil {
+		t.Fatalf("NewElasticsearchSink: %v", err)
+	}
+
+	event := log.NewEvent()
+	event.Query = "select 1"
+
+	if err := s.Write(context.Background(), event); err == nil {
+		t.Fatal("Write returned nil error for a bulk request that returned a 429")
+	}
+
+	if s.n == 0 || s.buf.Len() == 0 {
+		t.Fatalf("buffered event was dropped after a bulk error: n=%d, buf.Len()=%d", s.n, s.buf.Len())
+	}
+}
@@ -0,0 +1,99 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/percona/go-mysql/log"
+	"github.com/percona/go-mysql/query"
+	"github.com/pkg/errors"
+)
+
+// KafkaConfig configures a KafkaSink.
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+
+	// SaramaConfig is used as-is if set, otherwise sarama.NewConfig()
+	// defaults are used, plus Producer.Return.Successes = true (which
+	// sarama's SyncProducer requires) and Producer.Flush.Messages = 100 /
+	// Producer.Flush.Frequency = 500ms.
+	SaramaConfig *sarama.Config
+}
+
+// KafkaSink ships events to a Kafka topic using sarama's synchronous
+// producer, keying each message by the event's query fingerprint so that
+// all occurrences of the same query land on the same partition and can be
+// consumed in order.
+type KafkaSink struct {
+	topic    string
+	producer sarama.SyncProducer
+}
+
+// NewKafkaSink connects to cfg.Brokers and returns a sink that writes to
+// cfg.Topic.
+func NewKafkaSink(cfg KafkaConfig) (*KafkaSink, error) {
+	saramaCfg := cfg.SaramaConfig
+	if saramaCfg == nil {
+		saramaCfg = sarama.NewConfig()
+		saramaCfg.Producer.Return.Successes = true
+		// Batch a little instead of sending every event as its own
+		// produce request; slow logs are bursty and this is cheap to do.
+		saramaCfg.Producer.Flush.Messages = 100
+		saramaCfg.Producer.Flush.Frequency = 500 * time.Millisecond
+	}
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, saramaCfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create Kafka producer")
+	}
+
+	return &KafkaSink{
+		topic:    cfg.Topic,
+		producer: producer,
+	}, nil
+}
+
+func (s *KafkaSink) Write(ctx context.Context, event *log.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal event")
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(query.Fingerprint(event.Query)),
+		Value: sarama.ByteEncoder(payload),
+	}
+	_, _, err = s.producer.SendMessage(msg)
+	return errors.Wrap(err, "cannot send message to Kafka")
+}
+
+// Flush is a no-op: KafkaSink uses sarama's synchronous producer, so every
+// Write has already been acknowledged by the broker when it returns.
+func (s *KafkaSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *KafkaSink) Close() error {
+	return s.producer.Close()
+}
@@ -0,0 +1,175 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sink
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/percona/go-mysql/log"
+)
+
+// RetryPolicy controls how Pipeline retries a Sink.Write that returned an
+// error before giving up on that sink for the event and routing it to the
+// DLQ instead.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	// Default 3.
+	MaxAttempts int
+
+	// Backoff is the delay before the second attempt; it doubles after
+	// each subsequent failure. Default 500ms.
+	Backoff time.Duration
+}
+
+// PipelineConfig configures a Pipeline.
+type PipelineConfig struct {
+	// Transforms run in order on every event before it's sent to Sinks. A
+	// transform that returns ok=false drops the event.
+	Transforms []Transform
+
+	// Sinks every surviving event is written to. A slow Sink applies
+	// backpressure to the whole Pipeline: Run blocks until all of them
+	// finish (or give up on) the current event before reading the next
+	// one.
+	Sinks []Sink
+
+	Retry RetryPolicy
+
+	// DLQ receives events that exhausted Retry against one or more Sinks,
+	// so they aren't silently lost. May be nil to drop them instead. DLQ
+	// writes themselves are not retried.
+	DLQ Sink
+}
+
+// Pipeline reads events from a channel — typically a slow.SlowLogParser's
+// or slow.Tailer's EventChan — runs them through Transforms, and fans each
+// surviving event out to every Sink, retrying per-sink failures and
+// routing exhausted ones to a DLQ. It turns the module into a
+// self-contained slow-log ETL rather than just a parser.
+type Pipeline struct {
+	transforms []Transform
+	sinks      []Sink
+	retry      RetryPolicy
+	dlq        Sink
+}
+
+// NewPipeline returns a Pipeline built from cfg.
+func NewPipeline(cfg PipelineConfig) *Pipeline {
+	retry := cfg.Retry
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = 3
+	}
+	if retry.Backoff <= 0 {
+		retry.Backoff = 500 * time.Millisecond
+	}
+	return &Pipeline{
+		transforms: cfg.Transforms,
+		sinks:      cfg.Sinks,
+		retry:      retry,
+		dlq:        cfg.DLQ,
+	}
+}
+
+// Run reads from events until it's closed or ctx is done, processing each
+// one in turn. When events closes it flushes every sink and returns the
+// first Flush error, or nil if all flushes succeeded; it returns ctx.Err()
+// if ctx was done first.
+func (p *Pipeline) Run(ctx context.Context, events <-chan *log.Event) error {
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return p.flushAll(ctx)
+			}
+			p.process(ctx, event)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// process runs event through every transform, then fans it out to every
+// sink concurrently, waiting for all of them (the pipeline's backpressure
+// point) before returning.
+func (p *Pipeline) process(ctx context.Context, event *log.Event) {
+	for _, t := range p.transforms {
+		var ok bool
+		event, ok = t(event)
+		if !ok || event == nil {
+			return
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+	for _, s := range p.sinks {
+		wg.Add(1)
+		go func(s Sink) {
+			defer wg.Done()
+			if err := p.writeWithRetry(ctx, s, event); err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	if failed && p.dlq != nil {
+		p.dlq.Write(ctx, event) //nolint:errcheck // best-effort; nowhere left to route a DLQ failure
+	}
+}
+
+// writeWithRetry calls s.Write, retrying with exponential backoff per
+// p.retry until it succeeds, ctx is done, or attempts are exhausted.
+func (p *Pipeline) writeWithRetry(ctx context.Context, s Sink, event *log.Event) error {
+	backoff := p.retry.Backoff
+	var err error
+	for attempt := 0; attempt < p.retry.MaxAttempts; attempt++ {
+		if err = s.Write(ctx, event); err == nil {
+			return nil
+		}
+		if attempt == p.retry.MaxAttempts-1 {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// flushAll flushes every sink, returning the first error encountered (if
+// any) after attempting all of them.
+func (p *Pipeline) flushAll(ctx context.Context) error {
+	var firstErr error
+	for _, s := range p.sinks {
+		if err := s.Flush(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
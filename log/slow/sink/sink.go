@@ -0,0 +1,47 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+// Package sink ships log.Events parsed by slow.SlowLogParser or
+// slow.Tailer somewhere durable: Kafka, Elasticsearch, a SQL table, or a
+// plain file. A Pipeline wires a parser's event channel through optional
+// transforms into one or more Sinks.
+package sink
+
+import (
+	"context"
+
+	"github.com/percona/go-mysql/log"
+)
+
+// Sink writes events somewhere. Implementations should be safe to retry:
+// Pipeline calls Write again after a transient error, so Write must not
+// have partial, unrecoverable side effects on failure (e.g. a Kafka
+// producer should not have committed half a batch before returning an
+// error for it).
+type Sink interface {
+	// Write ships a single event. It may buffer internally; Flush forces
+	// anything buffered out.
+	Write(ctx context.Context, event *log.Event) error
+
+	// Flush blocks until everything Write has accepted so far has been
+	// durably sent.
+	Flush(ctx context.Context) error
+
+	// Close flushes and releases the sink's resources. A closed Sink must
+	// not be written to again.
+	Close() error
+}
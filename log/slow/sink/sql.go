@@ -0,0 +1,90 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/percona/go-mysql/log"
+	"github.com/percona/go-mysql/query"
+	"github.com/pkg/errors"
+)
+
+// SQLConfig configures a SQLSink.
+type SQLConfig struct {
+	DB *sql.DB
+
+	// Table to INSERT into. Its columns must be, in order: ts, user, host,
+	// db, fingerprint, query_time, lock_time, rows_sent, rows_examined,
+	// query. Use a view or generated columns if you need a different
+	// schema; SQLSink intentionally doesn't try to be a schema migration
+	// tool.
+	Table string
+}
+
+// SQLSink INSERTs one row per event into a database/sql table. It's the
+// simplest sink to stand up when Kafka or Elasticsearch would be overkill,
+// e.g. a small on-prem deployment shipping into the same MySQL the slow
+// log came from.
+type SQLSink struct {
+	db        *sql.DB
+	insertSQL string
+}
+
+func NewSQLSink(cfg SQLConfig) (*SQLSink, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("SQLConfig.DB is required")
+	}
+	if cfg.Table == "" {
+		return nil, errors.New("SQLConfig.Table is required")
+	}
+	return &SQLSink{
+		db: cfg.DB,
+		insertSQL: fmt.Sprintf(
+			`INSERT INTO %s (ts, user, host, db, fingerprint, query_time, lock_time, rows_sent, rows_examined, query) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			cfg.Table,
+		),
+	}, nil
+}
+
+func (s *SQLSink) Write(ctx context.Context, event *log.Event) error {
+	_, err := s.db.ExecContext(ctx, s.insertSQL,
+		event.Ts,
+		event.User,
+		event.Host,
+		event.Db,
+		query.Fingerprint(event.Query),
+		event.Metrics.QueryTime,
+		event.Metrics.LockTime,
+		event.Metrics.RowsSent,
+		event.Metrics.RowsExamined,
+		event.Query,
+	)
+	return errors.Wrap(err, "cannot insert event")
+}
+
+// Flush is a no-op: every Write already committed its own row.
+func (s *SQLSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *SQLSink) Close() error {
+	return nil
+}
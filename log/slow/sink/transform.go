@@ -0,0 +1,110 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sink
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/percona/go-mysql/log"
+	"github.com/percona/go-mysql/query"
+)
+
+// Transform inspects or mutates an event before Pipeline hands it to every
+// Sink. Returning ok=false drops the event: it reaches no sink and isn't
+// retried or sent to the DLQ.
+type Transform func(event *log.Event) (out *log.Event, ok bool)
+
+// FingerprintTransform sets event.Fingerprint from event.Query. Put it
+// first in a Pipeline's transform list if later transforms (or sinks) key
+// off the fingerprint instead of the raw query.
+func FingerprintTransform(event *log.Event) (*log.Event, bool) {
+	event.Fingerprint = query.Fingerprint(event.Query)
+	return event, true
+}
+
+// defaultMaxTrackedQueries bounds NewRateLimitSamplingTransform's per-query
+// counts when maxTrackedQueries <= 0 is passed.
+const defaultMaxTrackedQueries = 100000
+
+// NewRateLimitSamplingTransform returns a Transform that further thins the
+// stream on top of whatever sampling mysqld already did via
+// Log_slow_rate_limit: for a query seen with RateLimit N, it keeps 1 out of
+// every N occurrences instead of all of them. Events with RateLimit 0 or 1
+// (mysqld wasn't sampling) always pass through. Queries are grouped by
+// Fingerprint when set, otherwise by the raw Query text (run
+// FingerprintTransform first in a long-running Pipeline, since ungrouped
+// raw-query keys defeat the bound below).
+//
+// maxTrackedQueries caps how many distinct keys are counted at once; once
+// the cap is reached, the oldest key is evicted to make room for a new one,
+// resetting its count. Pass 0 for the default of 100000, enough headroom for
+// a typical fingerprint cardinality without growing unbounded on a
+// long-running Pipeline.
+func NewRateLimitSamplingTransform(maxTrackedQueries int) Transform {
+	if maxTrackedQueries <= 0 {
+		maxTrackedQueries = defaultMaxTrackedQueries
+	}
+	var (
+		mu     sync.Mutex
+		counts = make(map[string]uint64, maxTrackedQueries)
+		order  []string // insertion order, for FIFO eviction
+	)
+	return func(event *log.Event) (*log.Event, bool) {
+		if event.RateLimit <= 1 {
+			return event, true
+		}
+
+		key := event.Fingerprint
+		if key == "" {
+			key = event.Query
+		}
+
+		mu.Lock()
+		if _, seen := counts[key]; !seen {
+			if len(counts) >= maxTrackedQueries {
+				delete(counts, order[0])
+				order = order[1:]
+			}
+			order = append(order, key)
+		}
+		counts[key]++
+		keep := counts[key]%uint64(event.RateLimit) == 0
+		mu.Unlock()
+
+		return event, keep
+	}
+}
+
+var (
+	emailRe      = regexp.MustCompile(`[[:alnum:]._%+-]+@[[:alnum:].-]+\.[[:alpha:]]{2,}`)
+	creditCardRe = regexp.MustCompile(`\b(?:[0-9][ -]?){13,16}\b`)
+)
+
+// NewPIIScrubTransform returns a Transform that redacts values in Query
+// that look like emails or credit card numbers. It only touches the
+// human-readable Query field; run FingerprintTransform first if anything
+// downstream keys off Fingerprint, since scrubbing runs on the literal
+// query text.
+func NewPIIScrubTransform() Transform {
+	return func(event *log.Event) (*log.Event, bool) {
+		event.Query = emailRe.ReplaceAllString(event.Query, "[REDACTED_EMAIL]")
+		event.Query = creditCardRe.ReplaceAllString(event.Query, "[REDACTED_CC]")
+		return event, true
+	}
+}
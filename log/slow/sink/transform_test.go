@@ -0,0 +1,132 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sink
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/percona/go-mysql/log"
+)
+
+func TestRateLimitSamplingTransformKeepsOneInN(t *testing.T) {
+	transform := NewRateLimitSamplingTransform(0)
+
+	kept := 0
+	for i := 0; i < 10; i++ {
+		event := &log.Event{Query: "select 1", RateLimit: 5}
+		if _, ok := transform(event); ok {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Fatalf("kept %d of 10 events with RateLimit 5, want 2", kept)
+	}
+}
+
+func TestRateLimitSamplingTransformSurvivesByteWraparound(t *testing.T) {
+	// A count stored in a byte would wrap at 256, desyncing %RateLimit from
+	// the true occurrence count; exercise past that boundary.
+	transform := NewRateLimitSamplingTransform(0)
+
+	kept := 0
+	const occurrences = 259
+	const rateLimit = 7
+	for i := 0; i < occurrences; i++ {
+		event := &log.Event{Query: "select 1", RateLimit: rateLimit}
+		if _, ok := transform(event); ok {
+			kept++
+		}
+	}
+	if want := occurrences / rateLimit; kept != want {
+		t.Fatalf("kept %d of %d events with RateLimit %d, want %d", kept, occurrences, rateLimit, want)
+	}
+}
+
+func TestRateLimitSamplingTransformPassesUnsampled(t *testing.T) {
+	transform := NewRateLimitSamplingTransform(0)
+
+	for _, rl := range []byte{0, 1} {
+		event := &log.Event{Query: "select 1", RateLimit: rl}
+		if _, ok := transform(event); !ok {
+			t.Errorf("event with RateLimit %d was dropped, want always kept", rl)
+		}
+	}
+}
+
+func TestRateLimitSamplingTransformEvictsOldestKey(t *testing.T) {
+	transform := NewRateLimitSamplingTransform(2)
+
+	// Track two distinct keys up to the cap.
+	transform(&log.Event{Query: "select 1", RateLimit: 5})
+	transform(&log.Event{Query: "select 2", RateLimit: 5})
+
+	// A third distinct key evicts "select 1"'s count, so it restarts at 1
+	// instead of continuing from where it left off.
+	transform(&log.Event{Query: "select 3", RateLimit: 5})
+
+	var kept int
+	for i := 0; i < 4; i++ {
+		event := &log.Event{Query: "select 1", RateLimit: 5}
+		if _, ok := transform(event); ok {
+			kept++
+		}
+	}
+	if kept != 0 {
+		t.Fatalf("kept %d of 4 further events for an evicted+restarted key, want 0 (5th occurrence keeps)", kept)
+	}
+}
+
+func TestParseEventTs(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		ok   bool
+	}{
+		{"rfc3339nano", "2016-01-01T02:00:01.123456Z", true},
+		{"epoch seconds", "1468516800", true},
+		{"classic pre-5.7", "160101  2:00:01", true},
+		{"empty", "", false},
+		{"garbage", "not a timestamp", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := parseEventTs(tt.raw)
+			if ok != tt.ok {
+				t.Errorf("parseEventTs(%q) ok = %v, want %v", tt.raw, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIndexForFallsBackOnUnparseableTs(t *testing.T) {
+	s := &ElasticsearchSink{indexTemplate: "slowlog-2006.01.02"}
+	event := &log.Event{Ts: "not a timestamp"}
+	if got, want := s.indexFor(event), "slowlog-2006.01.02"; got != want {
+		t.Errorf("indexFor with unparseable Ts = %q, want fallback %q", got, want)
+	}
+}
+
+func TestIndexForFormatsClassicTimestamp(t *testing.T) {
+	s := &ElasticsearchSink{indexTemplate: "slowlog-2006.01.02"}
+	event := &log.Event{Ts: "160101  2:00:01"}
+	want := fmt.Sprintf("slowlog-%04d.%02d.%02d", 2016, 1, 1)
+	if got := s.indexFor(event); got != want {
+		t.Errorf("indexFor(classic Ts) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,511 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package slow
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	l "log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/klauspost/compress/zstd"
+	"github.com/percona/go-mysql/log"
+	"github.com/ulikunitz/xz"
+)
+
+// Checkpoint identifies a position in a (possibly rotated) slow log: a byte
+// offset plus the inode of the file it was read from. The inode lets a
+// resuming Tailer tell whether the file at Checkpoint time is still the
+// live file or has since been rotated away.
+type Checkpoint struct {
+	Offset uint64
+	Inode  uint64
+}
+
+// TailOptions configures a Tailer.
+type TailOptions struct {
+	// FollowRotation makes Tailer detect when the file at its path has
+	// been rotated (its inode changes) and transparently reopen it.
+	FollowRotation bool
+
+	// ResumeFrom, if set, picks up where a previous Tailer left off
+	// instead of reading the file from its beginning. If the checkpoint's
+	// inode doesn't match the current file, Tailer first replays any
+	// rotated segments (path.N, path.N.gz/.xz/.zst) newer than it.
+	ResumeFrom *Checkpoint
+
+	// IdlePollInterval is how often Tailer checks for file growth or
+	// rotation when it can't rely on inotify/kqueue. Default 1s.
+	IdlePollInterval time.Duration
+
+	// CheckpointEvery emits a Checkpoint on the Checkpoints channel after
+	// every N events. 0 (the default) disables checkpointing.
+	CheckpointEvery uint
+}
+
+// Tailer wraps a SlowLogParser to follow a live, growing slow log. Unlike
+// SlowLogParser.Start, which stops at EOF, Tailer keeps reading as the file
+// grows, reopens across rotation, and can replay compressed rotated
+// segments to catch up from a Checkpoint.
+type Tailer struct {
+	path    string
+	opt     log.Options
+	tailOpt TailOptions
+	// --
+	eventChan       chan *log.Event
+	checkpointChan  chan Checkpoint
+	stopChan        chan bool
+	stopped         bool
+	currentInode    uint64
+	sinceCheckpoint uint
+}
+
+// NewTailer creates a Tailer for the slow log at path. Call Start to begin
+// following it.
+func NewTailer(path string, opt log.Options, tailOpt TailOptions) *Tailer {
+	if tailOpt.IdlePollInterval <= 0 {
+		tailOpt.IdlePollInterval = time.Second
+	}
+	return &Tailer{
+		path:           path,
+		opt:            opt,
+		tailOpt:        tailOpt,
+		eventChan:      make(chan *log.Event),
+		checkpointChan: make(chan Checkpoint),
+		stopChan:       make(chan bool),
+	}
+}
+
+func (t *Tailer) EventChan() <-chan *log.Event { return t.eventChan }
+
+func (t *Tailer) Checkpoints() <-chan Checkpoint { return t.checkpointChan }
+
+func (t *Tailer) Stop() {
+	close(t.stopChan)
+}
+
+// Start follows the log until Stop is called or an unrecoverable error
+// occurs. It blocks, so callers typically run it in a goroutine.
+func (t *Tailer) Start() error {
+	defer close(t.eventChan)
+	defer close(t.checkpointChan)
+
+	if t.tailOpt.ResumeFrom != nil {
+		if err := t.replayRotated(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		rotated, err := t.tailOnce()
+		if err != nil {
+			return err
+		}
+		if t.stopped || !rotated {
+			return nil
+		}
+		if t.opt.Debug {
+			l.Println("reopening", t.path, "after rotation")
+		}
+	}
+}
+
+// tailOnce opens the file at t.path and follows it until Stop is called or
+// rotation is detected, returning rotated=true in the latter case so Start
+// knows to reopen.
+func (t *Tailer) tailOnce() (rotated bool, err error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	t.currentInode, _ = inodeOf(fi)
+
+	startOffset := uint64(0)
+	if t.tailOpt.ResumeFrom != nil && t.tailOpt.ResumeFrom.Inode == t.currentInode {
+		startOffset = t.tailOpt.ResumeFrom.Offset
+	}
+	t.tailOpt.ResumeFrom = nil // only meaningful for the first file we open
+	if _, err := file.Seek(int64(startOffset), os.SEEK_SET); err != nil {
+		return false, err
+	}
+
+	opt := t.opt
+	opt.StartOffset = startOffset
+	p := NewSlowLogParser(file, opt)
+
+	fr, err := newFollowReader(t.path, file, t.tailOpt, t.stopChan)
+	if err != nil {
+		return false, err
+	}
+	defer fr.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- p.parse(fr, t.reportOffset) }()
+
+	for event := range p.EventChan() {
+		select {
+		case t.eventChan <- event:
+		case <-t.stopChan:
+			t.stopped = true
+			fr.Close()
+		}
+	}
+
+	if err := <-done; err != nil {
+		return false, err
+	}
+	return fr.rotated, nil
+}
+
+func (t *Tailer) reportOffset(offset uint64) {
+	if t.tailOpt.CheckpointEvery == 0 {
+		return
+	}
+	t.sinceCheckpoint++
+	if t.sinceCheckpoint < t.tailOpt.CheckpointEvery {
+		return
+	}
+	t.sinceCheckpoint = 0
+	select {
+	case t.checkpointChan <- Checkpoint{Offset: offset, Inode: t.currentInode}:
+	case <-t.stopChan:
+	}
+}
+
+// replayRotated replays any rotated segments of t.path that are newer than
+// t.tailOpt.ResumeFrom, so a consumer resuming after its process restarted
+// sees the events it missed even if the live log rotated out from under
+// it. If ResumeFrom's inode matches the live file directly, there's nothing
+// to replay.
+func (t *Tailer) replayRotated() error {
+	if fi, err := os.Stat(t.path); err == nil {
+		if ino, ok := inodeOf(fi); ok && ino == t.tailOpt.ResumeFrom.Inode {
+			return nil
+		}
+	}
+
+	segments, err := rotatedSegments(t.path)
+	if err != nil {
+		return err
+	}
+
+	replaying := false
+	for _, seg := range segments {
+		if !replaying {
+			ino, ok := segmentInode(seg)
+			if !ok || ino != t.tailOpt.ResumeFrom.Inode {
+				continue
+			}
+			replaying = true
+			if err := t.replaySegmentFrom(seg, t.tailOpt.ResumeFrom.Offset); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := t.replaySegmentFrom(seg, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tailer) replaySegmentFrom(path string, offset uint64) error {
+	rc, err := decompressingReader(path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, rc, int64(offset)); err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	opt := t.opt
+	p := newReaderParser(opt)
+	done := make(chan error, 1)
+	go func() { done <- p.parse(rc, nil) }()
+
+	for event := range p.EventChan() {
+		select {
+		case t.eventChan <- event:
+		case <-t.stopChan:
+			t.stopped = true
+		}
+	}
+	return <-done
+}
+
+// --------------------------------------------------------------------------
+// Rotated segment discovery and decompression.
+
+var rotatedSegmentRe = regexp.MustCompile(`\.(\d+)(\.gz|\.xz|\.zst)?$`)
+
+// rotatedSegments returns the sibling files of path that look like rotated
+// copies of it (path.1, path.2.gz, ...), ordered oldest first.
+func rotatedSegments(path string) ([]string, error) {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type segment struct {
+		path string
+		n    int
+	}
+	var segments []segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		m := rotatedSegmentRe.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{filepath.Join(dir, name), n})
+	}
+
+	// logrotate numbers its backlog with .1 as the most recent rotation and
+	// higher numbers as older, so the oldest segment is the one with the
+	// highest N.
+	sort.Slice(segments, func(i, j int) bool { return segments[i].n > segments[j].n })
+
+	paths := make([]string, len(segments))
+	for i, s := range segments {
+		paths[i] = s.path
+	}
+	return paths, nil
+}
+
+func segmentInode(path string) (uint64, bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return inodeOf(fi)
+}
+
+func inodeOf(fi os.FileInfo) (uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return st.Ino, true
+}
+
+type closingReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (c closingReader) Close() error {
+	var firstErr error
+	for _, closer := range c.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// decompressingReader opens path, transparently wrapping it in a gzip, xz,
+// or zstd decoder if its extension says it's compressed.
+func decompressingReader(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("opening gzip segment %s: %w", path, err)
+		}
+		return closingReader{Reader: gz, closers: []io.Closer{gz, f}}, nil
+	case strings.HasSuffix(path, ".xz"):
+		xr, err := xz.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("opening xz segment %s: %w", path, err)
+		}
+		return closingReader{Reader: xr, closers: []io.Closer{f}}, nil
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("opening zstd segment %s: %w", path, err)
+		}
+		return closingReader{Reader: zr, closers: []io.Closer{zstdCloser{zr}, f}}, nil
+	default:
+		return f, nil
+	}
+}
+
+// zstdCloser adapts *zstd.Decoder's Close (which returns nothing) to
+// io.Closer.
+type zstdCloser struct{ d *zstd.Decoder }
+
+func (z zstdCloser) Close() error {
+	z.d.Close()
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Following a growing, possibly-rotating file.
+
+// followReader implements io.Reader over a file that's still being
+// appended to: Read blocks at EOF until more data arrives, the file is
+// rotated out from under it, or it's told to stop, rather than returning
+// io.EOF immediately like a normal file read would.
+type followReader struct {
+	path     string
+	file     *os.File
+	poll     time.Duration
+	follow   bool
+	stopChan chan bool
+	watcher  *fsnotify.Watcher
+	rotated  bool
+	closed   bool
+}
+
+func newFollowReader(path string, file *os.File, tailOpt TailOptions, stopChan chan bool) (*followReader, error) {
+	fr := &followReader{
+		path:     path,
+		file:     file,
+		poll:     tailOpt.IdlePollInterval,
+		follow:   tailOpt.FollowRotation,
+		stopChan: stopChan,
+	}
+	// A failed watcher isn't fatal: we fall back to polling, which is
+	// slower but still correct. Platforms without inotify/kqueue support
+	// (or containers where it's blocked) hit this path.
+	if watcher, err := fsnotify.NewWatcher(); err == nil {
+		if err := watcher.Add(filepath.Dir(path)); err == nil {
+			fr.watcher = watcher
+		} else {
+			watcher.Close()
+		}
+	}
+	return fr, nil
+}
+
+func (fr *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := fr.file.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+
+		if fr.follow {
+			if rotated, checkErr := fr.checkRotated(); checkErr == nil && rotated {
+				fr.rotated = true
+				return 0, io.EOF
+			}
+		}
+
+		if fr.closed {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-fr.stopChan:
+			return 0, io.EOF
+		case <-fr.wake():
+			continue
+		}
+	}
+}
+
+// wake returns a channel that fires when there might be more data to read:
+// on an fsnotify event if we have a working watcher, or on a timer
+// otherwise.
+func (fr *followReader) wake() <-chan time.Time {
+	if fr.watcher == nil {
+		return time.After(fr.poll)
+	}
+	select {
+	case <-fr.watcher.Events:
+	case <-fr.watcher.Errors:
+	case <-time.After(fr.poll):
+		// Still poll even with a watcher: some filesystems (overlayfs,
+		// some network mounts) don't deliver inotify/kqueue events
+		// reliably for in-place appends.
+	}
+	ch := make(chan time.Time, 1)
+	ch <- time.Now()
+	return ch
+}
+
+func (fr *followReader) checkRotated() (bool, error) {
+	fi, err := os.Stat(fr.path)
+	if err != nil {
+		// The file disappeared, which during rotation is transient; treat
+		// it the same as "not rotated yet" and let the next poll retry.
+		return false, nil
+	}
+	liveInode, ok := inodeOf(fi)
+	if !ok {
+		return false, nil
+	}
+	openFi, err := fr.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	openInode, _ := inodeOf(openFi)
+	return liveInode != openInode, nil
+}
+
+func (fr *followReader) Close() error {
+	if fr.closed {
+		return nil
+	}
+	fr.closed = true
+	if fr.watcher != nil {
+		return fr.watcher.Close()
+	}
+	return nil
+}
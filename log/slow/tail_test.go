@@ -0,0 +1,72 @@
+/*
+   Copyright (c) 2016, Percona LLC and/or its affiliates. All rights reserved.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU Affero General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU Affero General Public License for more details.
+
+   You should have received a copy of the GNU Affero General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package slow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatedSegmentsOrdersOldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "mysql-slow.log")
+
+	for _, name := range []string{"mysql-slow.log.1", "mysql-slow.log.2.gz", "mysql-slow.log.3.xz", "mysql-slow.log.4.zst"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A file that isn't a rotated segment of base shouldn't be picked up.
+	if err := os.WriteFile(filepath.Join(dir, "mysql-slow.log.bak"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := rotatedSegments(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "mysql-slow.log.4.zst"),
+		filepath.Join(dir, "mysql-slow.log.3.xz"),
+		filepath.Join(dir, "mysql-slow.log.2.gz"),
+		filepath.Join(dir, "mysql-slow.log.1"),
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("rotatedSegments returned %v, want %v", segments, want)
+	}
+	for i := range want {
+		if segments[i] != want[i] {
+			t.Errorf("segment %d = %q, want %q", i, segments[i], want[i])
+		}
+	}
+}
+
+func TestRotatedSegmentsNoMatches(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "mysql-slow.log")
+
+	segments, err := rotatedSegments(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 0 {
+		t.Fatalf("rotatedSegments on empty dir = %v, want none", segments)
+	}
+}